@@ -0,0 +1,151 @@
+package logger
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AsyncConfig wraps the stdout+file output in a non-blocking writer,
+// protecting latency-sensitive callers from stalls on the log disk.
+type AsyncConfig struct {
+	BufferSize     int    // records buffered before the overflow policy kicks in, defaults to 1024
+	OverflowPolicy string // "block" (default), "drop_oldest" or "drop_newest"
+}
+
+// asyncWriter drains a bounded channel of log records to an underlying
+// io.Writer on a background goroutine, so a stalled disk only blocks Write
+// once the buffer is full, and then only under the "block" policy.
+type asyncWriter struct {
+	out    io.Writer
+	policy string
+
+	// mux guards records/closed so Write can never send on the channel
+	// after Flush has closed it: Flush takes mux before closing records,
+	// and Write takes mux before every send, so the two can't race.
+	mux     sync.Mutex
+	closed  bool
+	records chan []byte
+
+	dropped uint64
+
+	flushOnce sync.Once
+	done      chan struct{}
+	stopTick  chan struct{}
+}
+
+func newAsyncWriter(out io.Writer, cnf *AsyncConfig) *asyncWriter {
+	size := 1024
+	policy := "block"
+
+	if cnf != nil {
+		if cnf.BufferSize > 0 {
+			size = cnf.BufferSize
+		}
+
+		if cnf.OverflowPolicy != "" {
+			policy = cnf.OverflowPolicy
+		}
+	}
+
+	w := &asyncWriter{
+		out:      out,
+		policy:   policy,
+		records:  make(chan []byte, size),
+		done:     make(chan struct{}),
+		stopTick: make(chan struct{}),
+	}
+
+	go w.drain()
+	go w.reportDropped()
+
+	return w
+}
+
+// errAsyncWriterClosed is returned by Write once Flush has started. Reload
+// moves the logger's output off a writer before flushing it, but the shutdown
+// flush in trap.OnKill leaves the logger pointing at the flushed writer, so
+// records logged after it get this error and are not written.
+var errAsyncWriterClosed = errors.New("async log writer is closed")
+
+func (w *asyncWriter) Write(p []byte) (int, error) {
+	record := append([]byte(nil), p...)
+
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	if w.closed {
+		return 0, errAsyncWriterClosed
+	}
+
+	switch w.policy {
+	case "drop_newest":
+		select {
+		case w.records <- record:
+		default:
+			atomic.AddUint64(&w.dropped, 1)
+		}
+	case "drop_oldest":
+		select {
+		case w.records <- record:
+		default:
+			select {
+			case <-w.records:
+				atomic.AddUint64(&w.dropped, 1)
+			default:
+			}
+
+			select {
+			case w.records <- record:
+			default:
+				atomic.AddUint64(&w.dropped, 1)
+			}
+		}
+	default: // "block"
+		w.records <- record
+	}
+
+	return len(p), nil
+}
+
+func (w *asyncWriter) drain() {
+	for record := range w.records {
+		if _, err := w.out.Write(record); err != nil {
+			l.l.WithError(err).Error("async log writer failed to write record")
+		}
+	}
+
+	close(w.done)
+}
+
+func (w *asyncWriter) reportDropped() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if n := atomic.SwapUint64(&w.dropped, 0); n > 0 {
+				l.l.WithField("dropped", n).Warn("dropped log records due to full async buffer")
+			}
+		case <-w.stopTick:
+			return
+		}
+	}
+}
+
+// Flush blocks until every buffered record has been written, then stops the
+// background goroutines. The writer must not be used afterwards.
+func (w *asyncWriter) Flush() {
+	w.flushOnce.Do(func() {
+		w.mux.Lock()
+		w.closed = true
+		close(w.records)
+		w.mux.Unlock()
+
+		<-w.done
+		close(w.stopTick)
+	})
+}
@@ -0,0 +1,36 @@
+package logger
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+)
+
+type loggerCtxKey struct{}
+
+// FromContext returns the Logger previously stored in ctx by WithContext, or
+// the package-default Logger if none was stored.
+func FromContext(ctx context.Context) Logger {
+	if lg, ok := ctx.Value(loggerCtxKey{}).(Logger); ok {
+		return lg
+	}
+
+	return l.l
+}
+
+// WithContext returns a copy of ctx carrying lg, retrievable with FromContext.
+func WithContext(ctx context.Context, lg Logger) context.Context {
+	return context.WithValue(ctx, loggerCtxKey{}, lg)
+}
+
+// With returns a copy of ctx whose Logger (see FromContext) has fields
+// merged in, so request-scoped data like a request ID or user flows through
+// call stacks without threading a Logger parameter everywhere.
+func With(ctx context.Context, fields ...logrus.Fields) context.Context {
+	lg := FromContext(ctx)
+
+	for _, f := range fields {
+		lg = lg.WithFields(f)
+	}
+
+	return WithContext(ctx, lg)
+}
@@ -0,0 +1,428 @@
+package logger
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	lsyslog "github.com/sirupsen/logrus/hooks/syslog"
+	"log/syslog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// HooksConfig declares the optional log sinks opened in addition to stdout
+// and the configured log file. Each sink is disabled unless its block is
+// present in the config.
+type HooksConfig struct {
+	Graylog       *GraylogHookConfig
+	Syslog        *SyslogHookConfig
+	Elasticsearch *ElasticsearchHookConfig
+	File          *FileHookConfig
+}
+
+// GraylogHookConfig ships log entries to a Graylog server using GELF.
+type GraylogHookConfig struct {
+	Addr     string // host:port of the GELF input
+	Protocol string // "udp" (default) or "tcp"
+	Level    string // minimum level to forward, defaults to debug
+}
+
+// SyslogHookConfig ships log entries to a syslog daemon.
+type SyslogHookConfig struct {
+	Network string // "udp", "tcp" or "" for the local syslog socket
+	Addr    string
+	Tag     string
+	Level   string
+}
+
+// ElasticsearchHookConfig ships log entries to Elasticsearch using the bulk
+// HTTP API.
+type ElasticsearchHookConfig struct {
+	URL   string // e.g. http://localhost:9200
+	Index string
+	Level string
+}
+
+// FileHookConfig ships log entries to a second file, independent of the
+// primary Config.File.
+type FileHookConfig struct {
+	Path  string
+	Level string
+}
+
+// closableHook is a logrus.Hook that owns a connection or file descriptor
+// which must be released when the hook is replaced.
+type closableHook interface {
+	logrus.Hook
+	Close() error
+}
+
+// buildHooks opens every sink configured in hc. It is the "open new" half of
+// Reload's open-new/swap/close-old sequence; the caller closes the hooks it
+// replaces once the new set is live.
+func buildHooks(hc *HooksConfig) ([]closableHook, error) {
+	if hc == nil {
+		return nil, nil
+	}
+
+	var hooks []closableHook
+
+	// Hooks already opened earlier in this call must be closed before
+	// returning an error, or a later sink failing to open (e.g. an
+	// unreachable syslog daemon) leaks every connection/fd opened before it -
+	// unboundedly, since Reload can be retried indefinitely via USR1.
+	if hc.Graylog != nil {
+		h, err := newGraylogHook(hc.Graylog)
+		if err != nil {
+			closeHooks(hooks)
+
+			return nil, fmt.Errorf("graylog hook: %w", err)
+		}
+
+		hooks = append(hooks, h)
+	}
+
+	if hc.Syslog != nil {
+		h, err := newSyslogHook(hc.Syslog)
+		if err != nil {
+			closeHooks(hooks)
+
+			return nil, fmt.Errorf("syslog hook: %w", err)
+		}
+
+		hooks = append(hooks, h)
+	}
+
+	if hc.Elasticsearch != nil {
+		h, err := newElasticsearchHook(hc.Elasticsearch)
+		if err != nil {
+			closeHooks(hooks)
+
+			return nil, fmt.Errorf("elasticsearch hook: %w", err)
+		}
+
+		hooks = append(hooks, h)
+	}
+
+	if hc.File != nil {
+		h, err := newFileHook(hc.File)
+		if err != nil {
+			closeHooks(hooks)
+
+			return nil, fmt.Errorf("file hook: %w", err)
+		}
+
+		hooks = append(hooks, h)
+	}
+
+	return hooks, nil
+}
+
+// swapHooks installs newHooks alongside the permanent hooks added via
+// AddHook, then closes whatever config-driven hooks they replace. Callers
+// must already hold l.mux.
+func swapHooks(newHooks []closableHook) {
+	replaced := make(logrus.LevelHooks)
+
+	for _, h := range l.permanentHooks {
+		replaced.Add(h)
+	}
+
+	for _, h := range newHooks {
+		replaced.Add(h)
+	}
+
+	l.log.ReplaceHooks(replaced)
+
+	oldHooks := l.hooks
+	l.hooks = newHooks
+
+	closeHooks(oldHooks)
+}
+
+func closeHooks(hooks []closableHook) {
+	for _, h := range hooks {
+		if err := h.Close(); err != nil {
+			l.l.WithError(err).Error("failed to close log hook")
+		}
+	}
+}
+
+// parseHookLevel parses a per-sink minimum level (e.g. GraylogHookConfig.Level
+// or Config.FileLevel), falling back - with a logged error on an invalid,
+// non-empty value - to fallback, consistent with applyLevel's handling of
+// Config.Level.
+func parseHookLevel(level string, fallback logrus.Level) logrus.Level {
+	if level == "" {
+		return fallback
+	}
+
+	lvl, err := logrus.ParseLevel(level)
+
+	if err != nil {
+		l.l.WithError(err).WithField("level", level).Error("invalid hook level, defaulting")
+
+		return fallback
+	}
+
+	return lvl
+}
+
+// levelFilterHook restricts an inner hook to levels at or above a configured
+// minimum, regardless of which levels the inner hook itself claims to fire on.
+type levelFilterHook struct {
+	inner closableHook
+	min   logrus.Level
+}
+
+func (h *levelFilterHook) Levels() []logrus.Level {
+	var levels []logrus.Level
+
+	for _, lvl := range h.inner.Levels() {
+		if lvl <= h.min {
+			levels = append(levels, lvl)
+		}
+	}
+
+	return levels
+}
+
+func (h *levelFilterHook) Fire(e *logrus.Entry) error { return h.inner.Fire(e) }
+func (h *levelFilterHook) Close() error               { return h.inner.Close() }
+
+// graylogHook sends GELF messages to Graylog over UDP or TCP.
+type graylogHook struct {
+	conn net.Conn
+}
+
+func newGraylogHook(c *GraylogHookConfig) (closableHook, error) {
+	proto := c.Protocol
+
+	if proto == "" {
+		proto = "udp"
+	}
+
+	conn, err := net.Dial(proto, c.Addr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &levelFilterHook{
+		inner: &graylogHook{conn: conn},
+		min:   parseHookLevel(c.Level, logrus.DebugLevel),
+	}, nil
+}
+
+func (h *graylogHook) Levels() []logrus.Level { return logrus.AllLevels }
+
+func (h *graylogHook) Fire(e *logrus.Entry) error {
+	msg, err := gelfMessage(e)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = h.conn.Write(msg)
+
+	return err
+}
+
+func (h *graylogHook) Close() error { return h.conn.Close() }
+
+// gelfMessage encodes a logrus entry as a gzip-compressed GELF message.
+func gelfMessage(e *logrus.Entry) ([]byte, error) {
+	hostname, _ := os.Hostname()
+
+	gelf := map[string]interface{}{
+		"version":       "1.1",
+		"host":          hostname,
+		"short_message": e.Message,
+		"timestamp":     float64(e.Time.UnixNano()) / float64(time.Second),
+		"level":         gelfSeverity(e.Level),
+	}
+
+	for k, v := range e.Data {
+		gelf["_"+k] = v
+	}
+
+	payload, err := json.Marshal(gelf)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+
+	gz := gzip.NewWriter(&buf)
+
+	if _, err := gz.Write(payload); err != nil {
+		return nil, err
+	}
+
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// gelfSeverity maps a logrus level onto the syslog severities GELF expects.
+func gelfSeverity(lvl logrus.Level) int {
+	switch lvl {
+	case logrus.PanicLevel, logrus.FatalLevel:
+		return 2
+	case logrus.ErrorLevel:
+		return 3
+	case logrus.WarnLevel:
+		return 4
+	case logrus.InfoLevel:
+		return 6
+	default:
+		return 7
+	}
+}
+
+// syslogHook adapts logrus's own syslog hook to the closableHook interface.
+type syslogHook struct {
+	*lsyslog.SyslogHook
+}
+
+func (h *syslogHook) Close() error {
+	// Release the syslog connection opened by lsyslog.NewSyslogHook.
+	return h.Writer.Close()
+}
+
+func newSyslogHook(c *SyslogHookConfig) (closableHook, error) {
+	hook, err := lsyslog.NewSyslogHook(c.Network, c.Addr, syslog.LOG_INFO, c.Tag)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &levelFilterHook{
+		inner: &syslogHook{hook},
+		min:   parseHookLevel(c.Level, logrus.DebugLevel),
+	}, nil
+}
+
+// elasticsearchHook ships entries to Elasticsearch using the bulk HTTP API.
+type elasticsearchHook struct {
+	url    string
+	index  string
+	client *http.Client
+}
+
+func newElasticsearchHook(c *ElasticsearchHookConfig) (closableHook, error) {
+	if c.URL == "" {
+		return nil, errors.New("elasticsearch url is required")
+	}
+
+	h := &elasticsearchHook{
+		url:    c.URL,
+		index:  c.Index,
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
+
+	return &levelFilterHook{inner: h, min: parseHookLevel(c.Level, logrus.DebugLevel)}, nil
+}
+
+func (h *elasticsearchHook) Levels() []logrus.Level { return logrus.AllLevels }
+
+func (h *elasticsearchHook) Fire(e *logrus.Entry) error {
+	meta, err := json.Marshal(map[string]interface{}{
+		"index": map[string]string{"_index": h.index},
+	})
+
+	if err != nil {
+		return err
+	}
+
+	doc := map[string]interface{}{
+		"@timestamp": e.Time,
+		"level":      e.Level.String(),
+		"message":    e.Message,
+	}
+
+	for k, v := range e.Data {
+		doc[k] = v
+	}
+
+	body, err := json.Marshal(doc)
+
+	if err != nil {
+		return err
+	}
+
+	bulk := append(append(meta, '\n'), append(body, '\n')...)
+
+	resp, err := h.client.Post(h.url+"/_bulk", "application/x-ndjson", bytes.NewReader(bulk))
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("elasticsearch bulk insert failed: %s", resp.Status)
+	}
+
+	return nil
+}
+
+func (h *elasticsearchHook) Close() error {
+	h.client.CloseIdleConnections()
+
+	return nil
+}
+
+// fileHook writes entries to a file separate from the primary Config.File.
+type fileHook struct {
+	fp *os.File
+}
+
+func newFileHook(c *FileHookConfig) (closableHook, error) {
+	if c.Path == "" {
+		return nil, errors.New("file hook path is required")
+	}
+
+	abs, err := filepath.Abs(c.Path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	fp, err := os.OpenFile(abs, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &levelFilterHook{
+		inner: &fileHook{fp: fp},
+		min:   parseHookLevel(c.Level, logrus.DebugLevel),
+	}, nil
+}
+
+func (h *fileHook) Levels() []logrus.Level { return logrus.AllLevels }
+
+func (h *fileHook) Fire(e *logrus.Entry) error {
+	line, err := e.String()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = h.fp.WriteString(line)
+
+	return err
+}
+
+func (h *fileHook) Close() error { return h.fp.Close() }
@@ -4,6 +4,7 @@
 package logger
 
 import (
+	"errors"
 	"github.com/sirupsen/logrus"
 	"io"
 	"os"
@@ -17,29 +18,50 @@ import (
 type Logger logrus.FieldLogger
 
 type logWrapper struct {
-	log  *logrus.Logger
-	fp   *os.File
-	l    *logrus.Entry
+	log *logrus.Logger
+	fp  *rotatingWriter
+	l   *logrus.Entry
 	mux sync.Mutex
+
+	hooks          []closableHook
+	permanentHooks []logrus.Hook
+
+	async *asyncWriter
 }
 
 // Config for the log package
 type Config struct {
-	File string
+	File   string
+	Level  string // "debug", "info", "warn", "error", ... defaults to "debug", applies to stdout
+	Format string // "text", "json" or "logfmt", defaults to "text", applies to stdout
+
+	// FileLevel and FileFormat override Level/Format for the file output
+	// only, leaving stdout on the settings above. Leave empty to have the
+	// file share stdout's level/format (the previous, still-default, behavior).
+	// Level still gates every entry before it reaches the file, so FileLevel
+	// can make the file less verbose than stdout but not more verbose.
+	FileLevel  string
+	FileFormat string
+
+	Rotate *RotateConfig
+	Hooks  *HooksConfig
+	Async  *AsyncConfig
 }
 
 var (
-	l *logWrapper
+	l   *logWrapper
 	cnf = &Config{}
 )
 
 func init() {
-	config.Add(&struct{Logger *Config}{Logger: cnf})
+	config.Add(&struct{ Logger *Config }{Logger: cnf})
 
 	l = &logWrapper{
 		log: logrus.New(),
 	}
 
+	// Applied again (from config) in Reload, but set here too so the
+	// initialization logs below aren't silently dropped by logrus's default level.
 	l.log.SetLevel(logrus.DebugLevel)
 
 	l.log.Debug("Initializing logger")
@@ -52,12 +74,24 @@ func init() {
 	} else {
 		// Cleanly close log file on shutdown
 		trap.OnKill(func() {
-			if l.fp == nil {
+			// l.async/l.fp are otherwise only read/written under l.mux (see
+			// Reload/setOutput), so read them the same way here instead of
+			// racing against a concurrent Reload.
+			l.mux.Lock()
+			async := l.async
+			fp := l.fp
+			l.mux.Unlock()
+
+			if async != nil {
+				async.Flush()
+			}
+
+			if fp == nil {
 				// Ignore when no file pointer specified
 				return
 			}
 
-			if err := l.fp.Close(); err != nil {
+			if err := fp.Close(); err != nil {
 				l.l.WithError(err).Error("Failed to close log file")
 			}
 		})
@@ -79,13 +113,135 @@ func init() {
 	l.l.Debug("Log initialized")
 }
 
-// New will return the Logger instance, with or without a package field
-func New(pkg string) Logger {
+// AddHook registers an additional logrus.Hook, e.g. to ship logs to a
+// transport not covered by Config.Hooks. Hooks added this way persist across
+// Reload and are not reconfigured by it.
+func AddHook(hook logrus.Hook) error {
+	if hook == nil {
+		return errors.New("hook must not be nil")
+	}
+
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	l.permanentHooks = append(l.permanentHooks, hook)
+	l.log.AddHook(hook)
+
+	return nil
+}
+
+// SetLevel sets the minimum level that will be logged. It is overridden by
+// Config.Level on the next Reload.
+func SetLevel(level logrus.Level) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	l.log.SetLevel(level)
+}
+
+// GetLevel returns the currently configured minimum level.
+func GetLevel() logrus.Level {
+	return l.log.GetLevel()
+}
+
+// SetFormatter sets the logrus formatter used for stdout, and for the log
+// file unless Config.FileLevel or Config.FileFormat gives the file its own
+// formatter. It is overridden by Config.Format on the next Reload.
+func SetFormatter(formatter logrus.Formatter) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	l.log.SetFormatter(formatter)
+}
+
+// New will return the Logger instance, with or without a package field, and
+// with any additional fields merged in.
+func New(pkg string, fields ...logrus.Fields) Logger {
+	lg := l.l
+
 	if pkg != "" {
-		return l.l.WithField("pkg", pkg)
+		lg = lg.WithField("pkg", pkg)
+	}
+
+	for _, f := range fields {
+		lg = lg.WithFields(f)
+	}
+
+	return lg
+}
+
+// applyLevel sets the logger level from its config string, defaulting to
+// debug when unset or unparseable.
+func applyLevel(level string) {
+	if level == "" {
+		l.log.SetLevel(logrus.DebugLevel)
+
+		return
+	}
+
+	lvl, err := logrus.ParseLevel(level)
+
+	if err != nil {
+		l.l.WithError(err).WithField("level", level).Error("invalid log level, defaulting to debug")
+
+		lvl = logrus.DebugLevel
+	}
+
+	l.log.SetLevel(lvl)
+}
+
+// applyFormat sets the logger formatter from its config string, defaulting
+// to logrus's plain text formatter.
+func applyFormat(format string) {
+	checkFormat(format)
+
+	l.log.SetFormatter(formatterFor(format))
+}
+
+// checkFormat logs when format is set to something other than a recognized
+// format name (or "", meaning "use the default"). Shared by Config.Format and
+// the FileFormat override, so a typo in either is diagnosed the same way.
+func checkFormat(format string) {
+	switch format {
+	case "", "text", "logfmt", "json":
+	default:
+		l.l.WithField("format", format).Error("unknown log format, defaulting to text")
+	}
+}
+
+// formatterFor returns the logrus.Formatter for a config format string,
+// defaulting to plain text for "" or an unknown value. Shared by the global
+// Config.Format and the FileFormat override used for the file output.
+func formatterFor(format string) logrus.Formatter {
+	switch format {
+	case "logfmt":
+		return &logrus.TextFormatter{DisableColors: true}
+	case "json":
+		return &logrus.JSONFormatter{}
+	default:
+		return &logrus.TextFormatter{}
 	}
+}
+
+// setOutput installs out as the logger output, wrapping it in an asyncWriter
+// when Config.Async is set, and flushes whichever asyncWriter it replaces.
+// Callers must already hold l.mux.
+func setOutput(out io.Writer) {
+	var newAsync *asyncWriter
+
+	if cnf.Async != nil {
+		newAsync = newAsyncWriter(out, cnf.Async)
+		out = newAsync
+	}
+
+	l.log.SetOutput(out)
 
-	return l.l
+	oldAsync := l.async
+	l.async = newAsync
+
+	if oldAsync != nil {
+		oldAsync.Flush()
+	}
 }
 
 // Reload will reload the log file if enabled through config.
@@ -104,10 +260,17 @@ func Reload() error {
 
 	l.l.Debug("About to (re)load log file")
 
+	applyLevel(cnf.Level)
+	applyFormat(cnf.Format)
+
 	if cnf.File == "" {
 		l.l.Debug("Not using log file, only stdout")
 
-		l.log.SetOutput(os.Stdout)
+		if err := swapHooksFor(nil); err != nil {
+			return err
+		}
+
+		setOutput(os.Stdout)
 
 		if l.fp != nil {
 			l.l.Debug("Closing previous log file")
@@ -134,21 +297,46 @@ func Reload() error {
 		l.l.WithField("path", abs).Debug("Log file")
 	}
 
-	prevFile := l.fp
+	var prevFile *rotatingWriter
 
-	newFile, err := os.OpenFile(abs, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
-	if err != nil {
-		l.l.WithError(err).Error("error opening file")
+	// Same file as before: USR1/Reload forces an immediate rotation (rename
+	// aside, fresh file, prune backups) instead of silently reopening in place.
+	if l.fp != nil && l.fp.path == abs {
+		l.l.Debug("Forcing log rotation")
 
-		return err
-	}
+		if err := l.fp.Rotate(); err != nil {
+			l.l.WithError(err).Error("failed to rotate log file")
 
-	// Unknown if this is the first time writing to the file, so just create some extra space!!
-	_, _ = newFile.WriteString("\n\n================\n\n\n")
+			return err
+		}
 
-	l.fp = newFile
+		l.l.Debug("Log file rotated")
+	} else {
+		prevFile = l.fp
 
-	l.log.SetOutput(io.MultiWriter(os.Stdout, l.fp))
+		newFile, err := newRotatingWriter(abs, cnf.Rotate)
+		if err != nil {
+			l.l.WithError(err).Error("error opening file")
+
+			return err
+		}
+
+		l.fp = newFile
+	}
+
+	if err := swapHooksFor(l.fp); err != nil {
+		return err
+	}
+
+	if cnf.FileLevel == "" && cnf.FileFormat == "" {
+		setOutput(io.MultiWriter(os.Stdout, l.fp))
+	} else {
+		// The file output has its own level/formatter, so it is fed through
+		// a hook (see swapHooksFor) rather than the combined stdout+file
+		// writer, which can only apply one format to both. Note this means
+		// an overridden file output bypasses Config.Async buffering.
+		setOutput(os.Stdout)
+	}
 
 	l.l.Debug("Log file (re)loaded")
 
@@ -164,3 +352,24 @@ func Reload() error {
 
 	return nil
 }
+
+// swapHooksFor builds the configured Config.Hooks sinks, adds a
+// FileLevel/FileFormat hook for fp when either override is set, and installs
+// the result via swapHooks. fp is nil when no log file is configured.
+func swapHooksFor(fp *rotatingWriter) error {
+	newHooks, err := buildHooks(cnf.Hooks)
+
+	if err != nil {
+		l.l.WithError(err).Error("failed to build log hooks")
+
+		return err
+	}
+
+	if fp != nil && (cnf.FileLevel != "" || cnf.FileFormat != "") {
+		newHooks = append(newHooks, newFileOutputHook(fp, cnf.FileLevel, cnf.FileFormat))
+	}
+
+	swapHooks(newHooks)
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package logger
+
+import (
+	"peterdekok.nl/gotools/trap"
+	"syscall"
+)
+
+// Reopen re-opens the current log file at its already configured path,
+// without re-reading config or triggering any other trap.OnReload
+// subscribers. It mirrors the "postrotate" step of logrotate: an external
+// rotator has already renamed the file out from under us, and all that is
+// left to do is drop the stale file descriptor for a fresh one.
+func Reopen() error {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	if l.fp == nil {
+		l.l.Debug("No log file configured, nothing to reopen")
+
+		return nil
+	}
+
+	l.l.Debug("Reopening log file")
+
+	return l.fp.Reopen()
+}
+
+func init() {
+	// trap.OnSignal is kept separate from trap.OnReload's USR1 handling, so
+	// SIGCONT only reopens the file and doesn't drag along every other
+	// trap.OnReload subscriber.
+	trap.OnSignal(syscall.SIGCONT, func() {
+		if err := Reopen(); err != nil {
+			l.l.WithError(err).Error("Failed to reopen log file")
+		}
+	})
+}
@@ -0,0 +1,100 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRotatingWriterSizeTriggeredRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.log")
+
+	w, err := newRotatingWriter(path, &RotateConfig{MaxSize: 1})
+	if err != nil {
+		t.Fatalf("newRotatingWriter: %v", err)
+	}
+	defer w.Close()
+
+	chunk := make([]byte, 512*1024)
+
+	for i := 0; i < 5; i++ {
+		if _, err := w.Write(chunk); err != nil {
+			t.Fatalf("write %d: %v", i, err)
+		}
+	}
+
+	if n := countBackups(t, dir); n < 2 {
+		t.Fatalf("expected multiple distinct backups from repeated size-triggered rotation, got %d", n)
+	}
+}
+
+func TestRotatingWriterPruneByMaxBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.log")
+
+	w, err := newRotatingWriter(path, &RotateConfig{MaxSize: 1, MaxBackups: 2})
+	if err != nil {
+		t.Fatalf("newRotatingWriter: %v", err)
+	}
+	defer w.Close()
+
+	chunk := make([]byte, 512*1024)
+
+	// 1 MB limit and 512 KB writes: writes 3, 5, 7 and 9 each rotate, so
+	// without pruning there would be 4 backups.
+	for i := 0; i < 10; i++ {
+		if _, err := w.Write(chunk); err != nil {
+			t.Fatalf("write %d: %v", i, err)
+		}
+	}
+
+	if n := countBackups(t, dir); n > 2 {
+		t.Fatalf("expected at most 2 backups after pruning, got %d", n)
+	}
+}
+
+func TestBackupNameIsUnique(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.log")
+
+	w := &rotatingWriter{path: path}
+
+	seen := map[string]bool{}
+
+	for i := 0; i < 3; i++ {
+		name := w.backupName()
+
+		if seen[name] {
+			t.Fatalf("backupName returned a duplicate: %s", name)
+		}
+
+		seen[name] = true
+
+		// A name already exists at this path (e.g. same wall-clock second as
+		// a previous rotation) is exactly the collision backupName must
+		// disambiguate against on the next call.
+		if err := os.WriteFile(name, []byte("x"), 0666); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+}
+
+func countBackups(t *testing.T, dir string) int {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+
+	var backups int
+
+	for _, e := range entries {
+		if e.Name() != "test.log" {
+			backups++
+		}
+	}
+
+	return backups
+}